@@ -0,0 +1,81 @@
+// Package crdcache implements a persistent, content-addressed cache for
+// CRDs fetched from remote sources, so repeated generator runs don't
+// re-download them and can optionally verify them against a pinned
+// checksum.
+package crdcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// Cache is a directory of CRD bytes keyed by the sha256 of their source URL.
+type Cache struct {
+	dir string
+}
+
+// New returns a Cache rooted at ~/.cache/x-generation/crds.
+func New() (*Cache, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, errors.Wrap(err, "resolving home directory for CRD cache")
+	}
+	return &Cache{dir: filepath.Join(home, ".cache", "x-generation", "crds")}, nil
+}
+
+func (c *Cache) entryPath(src string) string {
+	sum := sha256.Sum256([]byte(src))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+// Lookup returns the cached bytes for src, if present.
+func (c *Cache) Lookup(src string) (data []byte, ok bool, err error) {
+	b, err := ioutil.ReadFile(c.entryPath(src))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "reading cache entry for %s", src)
+	}
+	return b, true, nil
+}
+
+// Store writes data to the cache entry for src. The write is atomic
+// (write to a temp file in the cache dir, then rename into place) so
+// concurrent generators sharing a CRD source never observe a truncated or
+// partially-written entry.
+func (c *Cache) Store(src string, data []byte) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return errors.Wrap(err, "creating CRD cache directory")
+	}
+
+	tmp, err := ioutil.TempFile(c.dir, "tmp-*")
+	if err != nil {
+		return errors.Wrapf(err, "creating temp file for cache entry for %s", src)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "writing cache entry for %s", src)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "writing cache entry for %s", src)
+	}
+
+	if err := os.Rename(tmp.Name(), c.entryPath(src)); err != nil {
+		return errors.Wrapf(err, "writing cache entry for %s", src)
+	}
+	return nil
+}
+
+// Sha256 returns the hex-encoded sha256 digest of data.
+func Sha256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
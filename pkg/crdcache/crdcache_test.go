@@ -0,0 +1,60 @@
+package crdcache
+
+import "testing"
+
+func TestCacheLookupMiss(t *testing.T) {
+	c := &Cache{dir: t.TempDir()}
+
+	_, ok, err := c.Lookup("https://example.com/crd.yaml")
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	if ok {
+		t.Fatal("Lookup on an empty cache returned ok=true")
+	}
+}
+
+func TestCacheStoreThenLookup(t *testing.T) {
+	c := &Cache{dir: t.TempDir()}
+	src := "https://example.com/crd.yaml"
+	want := []byte("apiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\n")
+
+	if err := c.Store(src, want); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+
+	got, ok, err := c.Lookup(src)
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	if !ok {
+		t.Fatal("Lookup after Store returned ok=false")
+	}
+	if string(got) != string(want) {
+		t.Errorf("Lookup = %q, want %q", got, want)
+	}
+}
+
+func TestCacheEntriesAreKeyedBySource(t *testing.T) {
+	c := &Cache{dir: t.TempDir()}
+
+	if err := c.Store("https://example.com/a.yaml", []byte("a")); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+
+	_, ok, err := c.Lookup("https://example.com/b.yaml")
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	if ok {
+		t.Fatal("Lookup found an entry for a source that was never stored")
+	}
+}
+
+func TestSha256(t *testing.T) {
+	got := Sha256([]byte("hello"))
+	want := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if got != want {
+		t.Errorf("Sha256(\"hello\") = %s, want %s", got, want)
+	}
+}
@@ -10,13 +10,25 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/ghodss/yaml"
 	"github.com/google/go-cmp/cmp"
-	"github.com/google/go-jsonnet"
 	getter "github.com/hashicorp/go-getter"
 	"github.com/pkg/errors"
+
+	"github.com/jehadnasser/x-generation/pkg/crdcache"
+	"github.com/jehadnasser/x-generation/pkg/scriptengine"
+	"github.com/jehadnasser/x-generation/pkg/validate"
+)
+
+// Valid values for the -validate flag.
+const (
+	ValidateOff    = "off"
+	ValidateWarn   = "warn"
+	ValidateStrict = "strict"
 )
 
 const (
@@ -46,6 +58,9 @@ type Generator struct {
 	Plural               *string         `yaml:"plural,omitempty" json:"plural,omitempty"`
 	CRD                  string          `yaml:"crd" json:"crd"`
 	Version              string          `yaml:"version" json:"version"`
+	// ScriptFileName overrides the default generate.jsonnet lookup. Files
+	// ending in .star are evaluated with the Starlark engine instead of
+	// Jsonnet; see pkg/scriptengine.
 	ScriptFileName       *string         `yaml:"scriptFile,omitempty"`
 	ConnectionSecretKeys *[]string       `yaml:"connectionSecretKeys,omitempty" json:"connectionSecretKeys,omitempty"`
 	Ignore               bool            `yaml:"ignore"`
@@ -53,70 +68,159 @@ type Generator struct {
 	UIDFieldPath         *string         `yaml:"uidFieldPath,omitempty" json:"uidFieldPath,omitempty"`
 	OverrideFields       []OverrideField `yaml:"overrideFields" json:"overrideFields"`
 	Compositions         []Composition   `yaml:"compositions" json:"compositions"`
-	crdSource            string
-	configPath           string
+	// CRDSha256 pins the expected sha256 digest of the CRD fetched from
+	// CRD. When set, LoadCRD refuses to proceed if the downloaded (or
+	// cached) bytes don't match. When unset, the observed digest is
+	// recorded in generate.lock.yaml on first run.
+	CRDSha256 *string `yaml:"crdSha256,omitempty" json:"crdSha256,omitempty"`
+	// CRDRef is an informational label (e.g. a version or git ref) for
+	// the CRD source, recorded alongside CRDSha256 in generate.lock.yaml.
+	CRDRef     *string `yaml:"crdRef,omitempty" json:"crdRef,omitempty"`
+	crdSource  string
+	configPath string
 }
 
-type jsonnetOutput map[string]interface{}
+// crdLock is the on-disk shape of generate.lock.yaml, written next to a
+// generate.yaml the first time its CRD is fetched without a pinned
+// CRDSha256.
+type crdLock struct {
+	CRD    string `yaml:"crd"`
+	CRDRef string `yaml:"crdRef,omitempty"`
+	SHA256 string `yaml:"sha256"`
+}
 
-func (g *Generator) LoadConfig(path string) *Generator {
+func (g *Generator) LoadConfig(path string) (*Generator, error) {
 	g.configPath = filepath.Dir(path)
 	y, err := ioutil.ReadFile(path)
 	if err != nil {
-		log.Printf("Error loading generator: %+v", err)
+		return nil, errors.Wrapf(err, "loading generator %s", path)
 	}
-	err = yaml.Unmarshal(y, g)
-	if err != nil {
-		fmt.Printf("Error unmarshaling generator config: %v", err)
+	if err := yaml.Unmarshal(y, g); err != nil {
+		return nil, errors.Wrapf(err, "unmarshaling generator config %s", path)
 	}
-	return g
+	return g, nil
 }
 
-func (g *Generator) LoadCRD(inputPath string) {
-	crdTempDir, err := ioutil.TempDir("", "gencrd")
+// LoadCRD resolves the generator's CRD, preferring the on-disk cache over
+// a network fetch. If offline is true, it never hits the network and
+// fails if the CRD isn't already cached. If refresh is true, it ignores
+// any cached copy and re-fetches. When g.CRDSha256 is set, the resolved
+// bytes are verified against it and LoadCRD fails on mismatch; otherwise
+// the observed digest is recorded in generate.lock.yaml next to the
+// generator's config.
+func (g *Generator) LoadCRD(ctx context.Context, inputPath string, offline, refresh bool) error {
+	cache, err := crdcache.New()
 	if err != nil {
-		fmt.Printf("Error creating CRD temp dir: %v", err)
+		return err
 	}
 
-	defer os.RemoveAll(crdTempDir)
+	var crd []byte
+	if !refresh {
+		cached, ok, err := cache.Lookup(g.CRD)
+		if err != nil {
+			return err
+		}
+		if ok {
+			crd = cached
+		}
+	}
 
-	crdFileName := filepath.Base(g.CRD)
-	crdTempFile := filepath.Join(crdTempDir, crdFileName)
+	if crd == nil {
+		if offline {
+			return errors.Errorf("offline mode: no cached CRD for %s", g.CRD)
+		}
 
-	if err != nil {
-		fmt.Printf("Error creating CRD tempfile: %v", err)
+		crdTempDir, err := ioutil.TempDir("", "gencrd")
+		if err != nil {
+			return errors.Wrap(err, "creating CRD temp dir")
+		}
+		defer os.RemoveAll(crdTempDir)
+
+		crdFileName := filepath.Base(g.CRD)
+		crdTempFile := filepath.Join(crdTempDir, crdFileName)
+
+		client := &getter.Client{
+			Ctx: ctx,
+			Src: g.CRD,
+			Pwd: inputPath,
+			Dst: crdTempFile,
+		}
+
+		log.Printf("Retrieving CRD file from %s", g.CRD)
+		if err := client.Get(); err != nil {
+			return errors.Wrapf(err, "fetching CRD %s", g.CRD)
+		}
+
+		crd, err = ioutil.ReadFile(crdTempFile)
+		if err != nil {
+			return errors.Wrap(err, "reading CRD tempfile")
+		}
+
+		if err := cache.Store(g.CRD, crd); err != nil {
+			return err
+		}
 	}
 
-	client := &getter.Client{
-		Ctx: context.Background(),
-		Src: g.CRD,
-		Pwd: inputPath,
-		Dst: crdTempFile,
+	if len(crd) < 1 {
+		return errors.Errorf("CRD %s appears to be empty", g.CRD)
 	}
 
-	log.Printf("Retrieving CRD file from %s", g.CRD)
-	err = client.Get()
-	if err != nil {
-		fmt.Printf("Get CRD: %v", err)
+	sum := crdcache.Sha256(crd)
+	if g.CRDSha256 != nil {
+		if *g.CRDSha256 != sum {
+			return errors.Errorf("CRD %s: checksum mismatch: expected %s, got %s", g.CRD, *g.CRDSha256, sum)
+		}
+	} else if err := g.writeLockfile(sum); err != nil {
+		return err
 	}
 
-	crd, err := ioutil.ReadFile(crdTempFile)
+	r, err := yaml.YAMLToJSON(crd)
 	if err != nil {
-		fmt.Printf("Error reading from CRD tempfile: %v", err)
+		return errors.Wrap(err, "converting CRD to JSON")
 	}
+	g.crdSource = string(r)
+	return nil
+}
 
-	if len(crd) < 1 {
-		fmt.Printf("CRD %s appears to be empty!", g.CRD)
+// writeLockfile records the observed CRD digest in generate.lock.yaml next
+// to the generator's config, so a later run can pin CRDSha256 to it. An
+// existing lockfile is left as it is.
+func (g *Generator) writeLockfile(sha256 string) error {
+	lockPath := filepath.Join(g.configPath, "generate.lock.yaml")
+	if _, err := os.Stat(lockPath); err == nil {
+		return nil
 	}
 
-	r, err := yaml.YAMLToJSON(crd)
+	lock := crdLock{CRD: g.CRD, SHA256: sha256}
+	if g.CRDRef != nil {
+		lock.CRDRef = *g.CRDRef
+	}
+
+	y, err := yaml.Marshal(&lock)
 	if err != nil {
-		fmt.Printf("Convert CRD to JSON: %v", err)
+		return errors.Wrap(err, "marshaling generate.lock.yaml")
 	}
-	g.crdSource = string(r)
+
+	if err := ioutil.WriteFile(lockPath, y, 0644); err != nil {
+		return errors.Wrapf(err, "writing %s", lockPath)
+	}
+	return nil
 }
 
-func (g *Generator) Exec(scriptPath, scriptFileOverride, outputPath string) {
+// Exec evaluates the generator's script and writes its output to disk.
+//
+// If check is true, no files are written: instead, Exec prints a diff for
+// every file whose generated content would differ from what's on disk and
+// reports via its return value whether any drift was found, so callers
+// (e.g. CI) can fail the build. If write is false, Exec performs a dry
+// run: it prints the paths it would write without touching the
+// filesystem.
+//
+// validateMode controls whether generated Crossplane Compositions are
+// checked against the source CRD's OpenAPI schema: "off" skips validation,
+// "warn" prints violations without failing, and "strict" fails Exec if any
+// are found.
+func (g *Generator) Exec(scriptPath, scriptFileOverride, outputPath string, check, write bool, validateMode string) (bool, error) {
 	var fl string
 	if scriptFileOverride != "" {
 		fl = filepath.Join(scriptPath, scriptFileOverride)
@@ -127,25 +231,15 @@ func (g *Generator) Exec(scriptPath, scriptFileOverride, outputPath string) {
 		}
 	}
 
-	vm := jsonnet.MakeVM()
-
 	j, err := json.Marshal(&g)
 	if err != nil {
-		fmt.Printf("Error creating jsonnet input: %s", err)
+		return false, errors.Wrap(err, "creating script input")
 	}
-	vm.ExtVar("config", string(j))
-	vm.ExtVar("crd", g.crdSource)
 
-	r, err := vm.EvaluateFile(fl)
+	engine := scriptengine.ForFile(fl)
+	jso, err := engine.Evaluate(string(j), g.crdSource)
 	if err != nil {
-		fmt.Printf("Error applying function %s: %s", fl, err)
-	}
-
-	jso := make(jsonnetOutput)
-
-	err = json.Unmarshal([]byte(r), &jso)
-	if err != nil {
-		fmt.Printf("Error decoding jsonnet output: %s", err)
+		return false, errors.Wrapf(err, "applying function %s", fl)
 	}
 
 	outPath := g.configPath
@@ -157,22 +251,71 @@ func (g *Generator) Exec(scriptPath, scriptFileOverride, outputPath string) {
 		time.Now().Format("15:04:05 on 01-02-2006"),
 	))
 
+	var validator *validate.Validator
+	var specSchema json.RawMessage
+	var gvk validate.GVK
+	if validateMode != ValidateOff {
+		schema, g2, err := validate.SpecSchema(g.crdSource, g.Version)
+		if err != nil {
+			if validateMode == ValidateStrict {
+				return false, errors.Wrap(err, "extracting schema for validation")
+			}
+			fmt.Printf("Warning: could not extract schema for validation: %v\n", err)
+		} else if v, err := validate.Compile(schema); err != nil {
+			if validateMode == ValidateStrict {
+				return false, errors.Wrap(err, "compiling schema for validation")
+			}
+			fmt.Printf("Warning: could not compile schema for validation: %v\n", err)
+		} else {
+			validator = v
+			specSchema = schema
+			gvk = g2
+		}
+	}
+
+	// Validate every file before writing any of them: map iteration order
+	// is randomized, so interleaving validation with writes would let
+	// files earlier in that random order land on disk even though a
+	// later file's violation fails the run.
+	if validator != nil {
+		strictViolations := 0
+		for fn, fc := range jso {
+			doc, ok := fc.(map[string]interface{})
+			if !ok || !validate.IsComposition(doc) {
+				continue
+			}
+			fp := filepath.Join(outPath, fn) + ".yaml"
+			findings := validate.Resources(doc, gvk, validator, specSchema)
+			for _, f := range findings {
+				fmt.Printf("%s: %s: %s\n", fp, f.Pointer, f.Message)
+			}
+			strictViolations += len(findings)
+		}
+		if validateMode == ValidateStrict && strictViolations > 0 {
+			return false, errors.Errorf("%d validation error(s) found", strictViolations)
+		}
+	}
+
+	changed := false
+
 	for fn, fc := range jso {
 		yo, err := yaml.Marshal(fc)
 		if err != nil {
-			fmt.Printf("Error converting %s to YAML: %v", fn, err)
+			return changed, errors.Wrapf(err, "converting %s to YAML", fn)
 		}
 		fp := filepath.Join(outPath, fn) + ".yaml"
 
 		// Check if file already exists
+		ec := map[string]interface{}{}
+		exists := false
 		if _, err := os.Stat(fp); err == nil {
+			exists = true
 			yi, err := ioutil.ReadFile(fp)
 			if err != nil {
-				fmt.Printf("Error reading from existing output file: %v", err)
+				return changed, errors.Wrap(err, "reading from existing output file")
 			}
-			ec := map[string]interface{}{}
 			if err := yaml.Unmarshal(yi, &ec); err != nil {
-				fmt.Printf("Error unmarshaling existing output file: %v", err)
+				return changed, errors.Wrap(err, "unmarshaling existing output file")
 			}
 
 			if cmp.Equal(fc, ec) {
@@ -180,15 +323,72 @@ func (g *Generator) Exec(scriptPath, scriptFileOverride, outputPath string) {
 			}
 		}
 
-		fc := append(header, yo...)
-		err = ioutil.WriteFile(fp, fc, 0644)
-		if err != nil {
-			fmt.Printf("Error writing Generated File %s: %v", fp, err)
+		changed = true
+
+		if check {
+			fmt.Printf("%s would change:\n%s\n", fp, cmp.Diff(ec, fc))
+			continue
+		}
+
+		if !write {
+			verb := "update"
+			if !exists {
+				verb = "create"
+			}
+			fmt.Printf("Would %s %s\n", verb, fp)
+			continue
+		}
+
+		wfc := append(header, yo...)
+		if err := ioutil.WriteFile(fp, wfc, 0644); err != nil {
+			return changed, errors.Wrapf(err, "writing generated file %s", fp)
 		}
 	}
+
+	return changed, nil
+}
+
+// multiError aggregates the failures from one run of the worker pool into
+// a single error, so main can report every broken generator instead of
+// just the first one.
+type multiError []error
+
+func (m multiError) Error() string {
+	msgs := make([]string, len(m))
+	for i, err := range m {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d generator(s) failed:\n%s", len(m), strings.Join(msgs, "\n"))
+}
+
+// processGenerator loads, fetches the CRD for, and executes a single
+// generate.yaml found at configPath.
+func processGenerator(ctx context.Context, configPath, inputPath, scriptPath, scriptFile, outputPath string, check, write, offline, refresh bool, validateMode string) (bool, error) {
+	g, err := (&Generator{
+		OverrideFields: []OverrideField{},
+		Compositions:   []Composition{},
+	}).LoadConfig(configPath)
+	if err != nil {
+		return false, err
+	}
+
+	if g.Ignore {
+		log.Printf("Generator for %s asks to be ignored, skipping...", g.Name)
+		return false, nil
+	}
+
+	if err := g.LoadCRD(ctx, inputPath, offline, refresh); err != nil {
+		return false, errors.Wrapf(err, "generator %s", g.Name)
+	}
+
+	changed, err := g.Exec(scriptPath, scriptFile, outputPath, check, write, validateMode)
+	if err != nil {
+		return changed, errors.Wrapf(err, "generator %s", g.Name)
+	}
+	return changed, nil
 }
 
-func parseArgs(configFile, inputPath, scriptFile, scriptPath, outputPath *string) error {
+func parseArgs(configFile, inputPath, scriptFile, scriptPath, outputPath, validateMode *string, check, write *bool, parallelism *int, failFast, offline, refresh *bool) error {
 	cwd, err := os.Getwd()
 	if err != nil {
 		return err
@@ -205,35 +405,115 @@ func parseArgs(configFile, inputPath, scriptFile, scriptPath, outputPath *string
 	flag.StringVar(scriptFile, "scriptName", "", "script filename to execute against input file(s) (default: generate.jsonnet or specified in each input file)")
 	flag.StringVar(scriptPath, "scriptPath", sp, "path where script files are loaded from ")
 	flag.StringVar(outputPath, "outputPath", "", "path where output files are created (default: same directory as input file)")
+	flag.BoolVar(check, "check", false, "fail instead of writing if generated output would change (prints a diff for every drift)")
+	flag.BoolVar(write, "write", true, "write generated output to disk (set false for a dry run that only prints intended paths)")
+	flag.IntVar(parallelism, "parallelism", runtime.NumCPU(), "number of generators to run concurrently")
+	flag.BoolVar(failFast, "failFast", false, "cancel in-flight generators as soon as one fails")
+	flag.BoolVar(offline, "offline", false, "resolve CRDs from the local cache only, failing if an entry is missing")
+	flag.BoolVar(refresh, "refresh", false, "bypass the CRD cache and re-fetch from source")
+	flag.StringVar(validateMode, "validate", ValidateOff, "validate generated Compositions against the source CRD's schema: off, warn, or strict")
 
 	flag.Parse()
 
+	if *parallelism < 1 {
+		return errors.Errorf("-parallelism must be >= 1, got %d", *parallelism)
+	}
+
+	if *offline && *refresh {
+		return errors.New("-offline and -refresh are mutually exclusive: -refresh requires a network fetch, which -offline forbids")
+	}
+
+	switch *validateMode {
+	case ValidateOff, ValidateWarn, ValidateStrict:
+	default:
+		return errors.Errorf("invalid -validate value %q: must be one of off, warn, strict", *validateMode)
+	}
+
 	return nil
 }
 
+// jobResult is the outcome of running a single generate.yaml through
+// processGenerator.
+type jobResult struct {
+	path    string
+	changed bool
+	err     error
+}
+
 func main() {
-	var configFile, inputPath, scriptFile, scriptPath, outputPath string
+	var configFile, inputPath, scriptFile, scriptPath, outputPath, validateMode string
+	var check, write, failFast, offline, refresh bool
+	var parallelism int
 
-	if err := parseArgs(&configFile, &inputPath, &scriptFile, &scriptPath, &outputPath); err != nil {
-		fmt.Printf("Error parsing arguments: %s", err)
+	if err := parseArgs(&configFile, &inputPath, &scriptFile, &scriptPath, &outputPath, &validateMode, &check, &write, &parallelism, &failFast, &offline, &refresh); err != nil {
+		fmt.Printf("Error parsing arguments: %s\n", err)
+		os.Exit(1)
 	}
 
 	iGlob := filepath.Join(inputPath, "*/**/", configFile)
 	ml, err := filepath.Glob(iGlob)
 	if err != nil {
-		fmt.Printf("Error finding generator files matching %s: %s", iGlob, err)
+		fmt.Printf("Error finding generator files matching %s: %s\n", iGlob, err)
+		os.Exit(1)
 	}
 
-	for _, m := range ml {
-		g := (&Generator{
-			OverrideFields: []OverrideField{},
-			Compositions:   []Composition{},
-		}).LoadConfig(m)
-		if g.Ignore {
-			fmt.Printf("Generator for %s asks to be ignored, skipping...", g.Name)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	jobs := make(chan string)
+	results := make(chan jobResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for m := range jobs {
+				changed, err := processGenerator(ctx, m, inputPath, scriptPath, scriptFile, outputPath, check, write, offline, refresh, validateMode)
+				results <- jobResult{path: m, changed: changed, err: err}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for _, m := range ml {
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- m:
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var errs multiError
+	anyChanged := false
+
+	for r := range results {
+		if r.err != nil {
+			errs = append(errs, errors.Wrap(r.err, r.path))
+			if failFast {
+				cancel()
+			}
 			continue
 		}
-		g.LoadCRD(inputPath)
-		g.Exec(scriptPath, scriptFile, outputPath)
+		if r.changed {
+			anyChanged = true
+		}
+	}
+
+	if len(errs) > 0 {
+		fmt.Println(errs.Error())
+		os.Exit(1)
+	}
+
+	if check && anyChanged {
+		fmt.Println("Generated output is out of date, run without -check to update.")
+		os.Exit(1)
 	}
 }
\ No newline at end of file
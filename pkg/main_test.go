@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+// resetFlags points the global flag set at a fresh FlagSet and os.Args so
+// parseArgs (which registers flags on flag.CommandLine) can be called more
+// than once across test cases without panicking on redefinition.
+func resetFlags(args []string) {
+	flag.CommandLine = flag.NewFlagSet(args[0], flag.ContinueOnError)
+	os.Args = args
+}
+
+func mustParseArgs(args []string) error {
+	resetFlags(args)
+	var configFile, inputPath, scriptFile, scriptPath, outputPath, validateMode string
+	var check, write, failFast, offline, refresh bool
+	var parallelism int
+	return parseArgs(&configFile, &inputPath, &scriptFile, &scriptPath, &outputPath, &validateMode, &check, &write, &parallelism, &failFast, &offline, &refresh)
+}
+
+func TestParseArgsRejectsZeroParallelism(t *testing.T) {
+	if err := mustParseArgs([]string{"x-generation", "-parallelism=0"}); err == nil {
+		t.Fatal("expected an error for -parallelism=0")
+	}
+}
+
+func TestParseArgsRejectsOfflineWithRefresh(t *testing.T) {
+	if err := mustParseArgs([]string{"x-generation", "-offline", "-refresh"}); err == nil {
+		t.Fatal("expected an error for -offline combined with -refresh")
+	}
+}
+
+func TestParseArgsRejectsUnknownValidateMode(t *testing.T) {
+	if err := mustParseArgs([]string{"x-generation", "-validate=bogus"}); err == nil {
+		t.Fatal("expected an error for an unrecognized -validate value")
+	}
+}
+
+func TestParseArgsAcceptsDefaults(t *testing.T) {
+	if err := mustParseArgs([]string{"x-generation"}); err != nil {
+		t.Fatalf("parseArgs with no flags: %v", err)
+	}
+}
+
+// TestMainExitsNonZeroOnInvalidParallelism re-execs the test binary as
+// main() itself (rather than calling parseArgs in-process) so it exercises
+// the thing a reviewer actually cares about: that an argument validation
+// failure surfaces as a non-zero exit status to main's caller, not just as
+// an error value that something might forget to check.
+func TestMainExitsNonZeroOnInvalidParallelism(t *testing.T) {
+	if os.Getenv("X_GENERATION_TEST_MAIN") == "1" {
+		os.Args = []string{"x-generation", "-parallelism=0"}
+		main()
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=TestMainExitsNonZeroOnInvalidParallelism")
+	cmd.Env = append(os.Environ(), "X_GENERATION_TEST_MAIN=1")
+	err := cmd.Run()
+
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() == 0 {
+		t.Fatalf("expected main() to exit non-zero for -parallelism=0, got %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package scriptengine
+
+import (
+	"encoding/json"
+
+	"github.com/google/go-jsonnet"
+)
+
+// JsonnetEngine evaluates a generate.jsonnet script with the go-jsonnet VM,
+// passing config/crd in as the `config`/`crd` external variables.
+type JsonnetEngine struct {
+	path string
+}
+
+// NewJsonnetEngine returns an Engine that evaluates the Jsonnet script at path.
+func NewJsonnetEngine(path string) *JsonnetEngine {
+	return &JsonnetEngine{path: path}
+}
+
+func (e *JsonnetEngine) Evaluate(config, crd string) (map[string]interface{}, error) {
+	vm := jsonnet.MakeVM()
+	vm.ExtVar("config", config)
+	vm.ExtVar("crd", crd)
+
+	r, err := vm.EvaluateFile(e.path)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(r), &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
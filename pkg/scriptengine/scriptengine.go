@@ -0,0 +1,20 @@
+// Package scriptengine abstracts over the scripting languages that a
+// generator's config/CRD pair can be evaluated with. Currently Jsonnet
+// (the original engine) and Starlark are supported.
+package scriptengine
+
+// Engine evaluates a generator script against the generator's config and
+// CRD (both passed as JSON strings) and returns the produced output as a
+// map of output filename to YAML-serializable payload.
+type Engine interface {
+	Evaluate(config, crd string) (map[string]interface{}, error)
+}
+
+// ForFile returns the Engine that should be used to evaluate the script at
+// path, selected by its file extension.
+func ForFile(path string) Engine {
+	if HasStarlarkExt(path) {
+		return NewStarlarkEngine(path)
+	}
+	return NewJsonnetEngine(path)
+}
@@ -0,0 +1,199 @@
+package scriptengine
+
+import (
+	"encoding/json"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.starlark.net/starlark"
+)
+
+// starlarkExt is the file extension used to identify generate.star scripts.
+const starlarkExt = ".star"
+
+// HasStarlarkExt reports whether path looks like a Starlark script.
+func HasStarlarkExt(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), starlarkExt)
+}
+
+// StarlarkEngine evaluates a generate.star script. The script must define a
+// main(ctx) function, where ctx is a dict with "config" and "crd" keys
+// (both parsed from JSON), and which returns a dict whose keys are output
+// filenames and whose values are the YAML-serializable payloads.
+type StarlarkEngine struct {
+	path string
+}
+
+// NewStarlarkEngine returns an Engine that evaluates the Starlark script at path.
+func NewStarlarkEngine(path string) *StarlarkEngine {
+	return &StarlarkEngine{path: path}
+}
+
+func (e *StarlarkEngine) Evaluate(config, crd string) (map[string]interface{}, error) {
+	configVal, err := jsonToStarlark(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse config as JSON")
+	}
+	crdVal, err := jsonToStarlark(crd)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse crd as JSON")
+	}
+
+	ctx := starlark.NewDict(2)
+	if err := ctx.SetKey(starlark.String("config"), configVal); err != nil {
+		return nil, err
+	}
+	if err := ctx.SetKey(starlark.String("crd"), crdVal); err != nil {
+		return nil, err
+	}
+
+	thread := &starlark.Thread{Name: "x-generation"}
+	globals, err := starlark.ExecFile(thread, e.path, nil, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "executing %s", e.path)
+	}
+
+	main, ok := globals["main"]
+	if !ok {
+		return nil, errors.Errorf("%s does not define a main(ctx) function", e.path)
+	}
+
+	result, err := starlark.Call(thread, main, starlark.Tuple{ctx}, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "calling main(ctx) in %s", e.path)
+	}
+
+	out, err := starlarkToGo(result)
+	if err != nil {
+		return nil, err
+	}
+
+	m, ok := out.(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("main(ctx) in %s must return a dict", e.path)
+	}
+	return m, nil
+}
+
+// jsonToStarlark parses a JSON string into a starlark.Value, reusing the
+// same map[string]interface{}/[]interface{} decoding that the rest of the
+// generator uses for config/crd.
+func jsonToStarlark(s string) (starlark.Value, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return nil, err
+	}
+	return goToStarlark(v)
+}
+
+// wholeInt64 reports whether f is an integral value that fits in an
+// int64, so JSON numbers like `replicas: 3` round-trip as starlark.Int
+// rather than starlark.Float — Starlark is strict about the distinction
+// (range(), indexing, and dict keys all require Int).
+func wholeInt64(f float64) (int64, bool) {
+	if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+func goToStarlark(v interface{}) (starlark.Value, error) {
+	switch t := v.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(t), nil
+	case float64:
+		if i, ok := wholeInt64(t); ok {
+			return starlark.MakeInt64(i), nil
+		}
+		return starlark.Float(t), nil
+	case string:
+		return starlark.String(t), nil
+	case []interface{}:
+		elems := make([]starlark.Value, len(t))
+		for i, e := range t {
+			sv, err := goToStarlark(e)
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = sv
+		}
+		return starlark.NewList(elems), nil
+	case map[string]interface{}:
+		d := starlark.NewDict(len(t))
+		for k, e := range t {
+			sv, err := goToStarlark(e)
+			if err != nil {
+				return nil, err
+			}
+			if err := d.SetKey(starlark.String(k), sv); err != nil {
+				return nil, err
+			}
+		}
+		return d, nil
+	default:
+		return nil, errors.Errorf("unsupported JSON value of type %T", v)
+	}
+}
+
+// starlarkToGo recursively converts a starlark.Value produced by a
+// generate.star script into plain Go values (map[string]interface{},
+// []interface{}, string, int64/float64, bool, nil) so it can be marshaled
+// to YAML by the existing write path.
+func starlarkToGo(v starlark.Value) (interface{}, error) {
+	switch t := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(t), nil
+	case starlark.Int:
+		i, ok := t.Int64()
+		if !ok {
+			return nil, errors.Errorf("int %s does not fit in int64", t.String())
+		}
+		return i, nil
+	case starlark.Float:
+		return float64(t), nil
+	case starlark.String:
+		return string(t), nil
+	case starlark.Tuple:
+		out := make([]interface{}, t.Len())
+		for i := 0; i < t.Len(); i++ {
+			ev, err := starlarkToGo(t.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			out[i] = ev
+		}
+		return out, nil
+	case *starlark.List:
+		out := make([]interface{}, t.Len())
+		for i := 0; i < t.Len(); i++ {
+			ev, err := starlarkToGo(t.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			out[i] = ev
+		}
+		return out, nil
+	case *starlark.Dict:
+		out := make(map[string]interface{}, t.Len())
+		for _, item := range t.Items() {
+			k, ok := starlark.AsString(item[0])
+			if !ok {
+				return nil, errors.Errorf("dict key %s is not a string", item[0].String())
+			}
+			ev, err := starlarkToGo(item[1])
+			if err != nil {
+				return nil, err
+			}
+			out[k] = ev
+		}
+		return out, nil
+	default:
+		return nil, errors.Errorf("unsupported Starlark value of type %T", v)
+	}
+}
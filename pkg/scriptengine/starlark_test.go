@@ -0,0 +1,91 @@
+package scriptengine
+
+import (
+	"testing"
+
+	"go.starlark.net/starlark"
+)
+
+func TestHasStarlarkExt(t *testing.T) {
+	cases := map[string]bool{
+		"generate.star": true,
+		"generate.STAR": true,
+		"generate.jsonnet": false,
+		"generate.yaml": false,
+	}
+	for path, want := range cases {
+		if got := HasStarlarkExt(path); got != want {
+			t.Errorf("HasStarlarkExt(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestGoToStarlarkWholeFloatsBecomeInt(t *testing.T) {
+	v, err := goToStarlark(float64(3))
+	if err != nil {
+		t.Fatalf("goToStarlark: %v", err)
+	}
+	if _, ok := v.(starlark.Int); !ok {
+		t.Fatalf("goToStarlark(3.0) = %T, want starlark.Int", v)
+	}
+
+	v, err = goToStarlark(float64(3.5))
+	if err != nil {
+		t.Fatalf("goToStarlark: %v", err)
+	}
+	if _, ok := v.(starlark.Float); !ok {
+		t.Fatalf("goToStarlark(3.5) = %T, want starlark.Float", v)
+	}
+}
+
+func TestGoToStarlarkRoundTrip(t *testing.T) {
+	in := map[string]interface{}{
+		"name":     "widget",
+		"replicas": float64(3),
+		"ratio":    float64(0.5),
+		"enabled":  true,
+		"tags":     []interface{}{"a", "b"},
+		"nil":      nil,
+	}
+
+	sv, err := goToStarlark(in)
+	if err != nil {
+		t.Fatalf("goToStarlark: %v", err)
+	}
+
+	out, err := starlarkToGo(sv)
+	if err != nil {
+		t.Fatalf("starlarkToGo: %v", err)
+	}
+
+	m, ok := out.(map[string]interface{})
+	if !ok {
+		t.Fatalf("starlarkToGo returned %T, want map[string]interface{}", out)
+	}
+
+	if m["name"] != "widget" {
+		t.Errorf("name = %v, want widget", m["name"])
+	}
+	if m["replicas"] != int64(3) {
+		t.Errorf("replicas = %v (%T), want int64(3)", m["replicas"], m["replicas"])
+	}
+	if m["ratio"] != float64(0.5) {
+		t.Errorf("ratio = %v, want 0.5", m["ratio"])
+	}
+	if m["enabled"] != true {
+		t.Errorf("enabled = %v, want true", m["enabled"])
+	}
+	tags, ok := m["tags"].([]interface{})
+	if !ok || len(tags) != 2 || tags[0] != "a" || tags[1] != "b" {
+		t.Errorf("tags = %v, want [a b]", m["tags"])
+	}
+	if m["nil"] != nil {
+		t.Errorf("nil = %v, want nil", m["nil"])
+	}
+}
+
+func TestJsonToStarlarkInvalidJSON(t *testing.T) {
+	if _, err := jsonToStarlark("{not json"); err == nil {
+		t.Fatal("expected an error for invalid JSON")
+	}
+}
@@ -0,0 +1,92 @@
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// IsComposition reports whether doc (a generated file, decoded from
+// YAML/JSON) looks like a Crossplane Composition.
+func IsComposition(doc map[string]interface{}) bool {
+	kind, _ := doc["kind"].(string)
+	return kind == "Composition"
+}
+
+// Resources validates every resource in a Composition whose base's
+// apiVersion/kind matches gvk against schema, the `spec` schema of that
+// GVK's CRD (as returned by SpecSchema), using the compiled form v for
+// literal values. It returns one Finding per violation found:
+//   - in base.spec, for fields set to literal values (Pointer is the
+//     violation's JSON pointer within base.spec appended to the resource,
+//     like "resources[2].base.spec/forProvider/region")
+//   - in patches[].toFieldPath targeting spec.*, for field paths the
+//     schema doesn't define (Pointer like "resources[2].patches[0].toFieldPath") —
+//     this is the common case, since real Compositions set forProvider
+//     fields via patches rather than hardcoding them in base.
+func Resources(doc map[string]interface{}, gvk GVK, v *Validator, schema json.RawMessage) []Finding {
+	spec, ok := doc["spec"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	resources, ok := spec["resources"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	apiVersion := gvk.Group + "/" + gvk.Version
+
+	var findings []Finding
+	for i, r := range resources {
+		res, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		base, ok := res["base"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if av, _ := base["apiVersion"].(string); av != apiVersion {
+			continue
+		}
+		if k, _ := base["kind"].(string); k != gvk.Kind {
+			continue
+		}
+
+		if baseSpec, ok := base["spec"]; ok {
+			for _, f := range v.Validate(baseSpec) {
+				findings = append(findings, Finding{
+					Pointer: fmt.Sprintf("resources[%d].base.spec%s", i, f.Pointer),
+					Message: f.Message,
+				})
+			}
+		}
+
+		patches, _ := res["patches"].([]interface{})
+		for j, p := range patches {
+			patch, ok := p.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			toFieldPath, _ := patch["toFieldPath"].(string)
+			rest := strings.TrimPrefix(toFieldPath, "spec.")
+			if rest == "" || rest == toFieldPath {
+				// Not set, or doesn't target the composed resource's spec
+				// (e.g. a patch to metadata.labels) — nothing to check.
+				continue
+			}
+
+			exists, err := FieldPathExists(schema, rest)
+			if err != nil || exists {
+				continue
+			}
+
+			findings = append(findings, Finding{
+				Pointer: fmt.Sprintf("resources[%d].patches[%d].toFieldPath", i, j),
+				Message: fmt.Sprintf("field path %q does not exist in the CRD schema", toFieldPath),
+			})
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,112 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+)
+
+func testGVK() GVK {
+	return GVK{Group: "example.org", Version: "v1", Kind: "Thing"}
+}
+
+func mustValidator(t *testing.T) *Validator {
+	t.Helper()
+	v, err := Compile([]byte(testSpecSchema))
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	return v
+}
+
+func TestIsComposition(t *testing.T) {
+	if !IsComposition(map[string]interface{}{"kind": "Composition"}) {
+		t.Error("expected a Composition doc to be recognized")
+	}
+	if IsComposition(map[string]interface{}{"kind": "CompositeResourceDefinition"}) {
+		t.Error("expected a non-Composition doc to be rejected")
+	}
+}
+
+func TestResourcesFlagsUnknownPatchedField(t *testing.T) {
+	doc := map[string]interface{}{
+		"kind": "Composition",
+		"spec": map[string]interface{}{
+			"resources": []interface{}{
+				map[string]interface{}{
+					"base": map[string]interface{}{
+						"apiVersion": "example.org/v1",
+						"kind":       "Thing",
+						"spec":       map[string]interface{}{},
+					},
+					"patches": []interface{}{
+						map[string]interface{}{
+							"fromFieldPath": "spec.region",
+							"toFieldPath":   "spec.forProvider.region",
+						},
+						map[string]interface{}{
+							"fromFieldPath": "spec.bogus",
+							"toFieldPath":   "spec.forProvider.bogus",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	findings := Resources(doc, testGVK(), mustValidator(t), []byte(testSpecSchema))
+	if len(findings) != 1 {
+		t.Fatalf("got %d findings, want 1: %+v", len(findings), findings)
+	}
+	if !strings.Contains(findings[0].Pointer, "patches[1]") {
+		t.Errorf("finding Pointer = %q, want it to reference patches[1]", findings[0].Pointer)
+	}
+}
+
+func TestResourcesSkipsNonMatchingBase(t *testing.T) {
+	doc := map[string]interface{}{
+		"kind": "Composition",
+		"spec": map[string]interface{}{
+			"resources": []interface{}{
+				map[string]interface{}{
+					"base": map[string]interface{}{
+						"apiVersion": "other.org/v1",
+						"kind":       "Other",
+						"spec":       map[string]interface{}{},
+					},
+					"patches": []interface{}{
+						map[string]interface{}{"toFieldPath": "spec.forProvider.bogus"},
+					},
+				},
+			},
+		},
+	}
+
+	findings := Resources(doc, testGVK(), mustValidator(t), []byte(testSpecSchema))
+	if len(findings) != 0 {
+		t.Fatalf("got %d findings for a non-matching base, want 0: %+v", len(findings), findings)
+	}
+}
+
+func TestResourcesValidatesLiteralBaseSpec(t *testing.T) {
+	doc := map[string]interface{}{
+		"kind": "Composition",
+		"spec": map[string]interface{}{
+			"resources": []interface{}{
+				map[string]interface{}{
+					"base": map[string]interface{}{
+						"apiVersion": "example.org/v1",
+						"kind":       "Thing",
+						"spec": map[string]interface{}{
+							"forProvider": "not-an-object",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	findings := Resources(doc, testGVK(), mustValidator(t), []byte(testSpecSchema))
+	if len(findings) == 0 {
+		t.Fatal("expected a finding for a literal base.spec value of the wrong type")
+	}
+}
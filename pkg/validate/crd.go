@@ -0,0 +1,82 @@
+// Package validate checks generated Crossplane Compositions against the
+// OpenAPI schema embedded in their source CRD: it validates literal
+// values in each matching resource's `base.spec`, and checks that the
+// field paths referenced by `patches[].toFieldPath` actually exist in
+// that schema (e.g. catching a patch targeting a non-existent
+// `spec.forProvider.*` field).
+package validate
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// GVK identifies the group/version/kind a Composition's resource bases are
+// expected to match.
+type GVK struct {
+	Group   string
+	Version string
+	Kind    string
+}
+
+// crdDoc is the subset of a CustomResourceDefinition this package reads.
+type crdDoc struct {
+	Spec struct {
+		Group string `json:"group"`
+		Names struct {
+			Kind string `json:"kind"`
+		} `json:"names"`
+		Versions []struct {
+			Name    string `json:"name"`
+			Served  bool   `json:"served"`
+			Schema  struct {
+				OpenAPIV3Schema json.RawMessage `json:"openAPIV3Schema"`
+			} `json:"schema"`
+		} `json:"versions"`
+	} `json:"spec"`
+}
+
+// SpecSchema extracts the `spec` property of version's openAPIV3Schema
+// from crdJSON (the CRD, as JSON), along with the GVK that schema
+// describes. version should be the generator's configured Version; it
+// must name a version that exists and is served, so the schema matches
+// what the generator actually targets (a CRD commonly serves more than
+// one version, e.g. a deprecated v1beta1 alongside v1).
+func SpecSchema(crdJSON, version string) (json.RawMessage, GVK, error) {
+	var doc crdDoc
+	if err := json.Unmarshal([]byte(crdJSON), &doc); err != nil {
+		return nil, GVK{}, errors.Wrap(err, "parsing CRD")
+	}
+
+	var available []string
+	for _, v := range doc.Spec.Versions {
+		available = append(available, v.Name)
+		if v.Name != version {
+			continue
+		}
+		if !v.Served {
+			return nil, GVK{}, errors.Errorf("CRD version %s is not served", version)
+		}
+
+		var schema struct {
+			Properties struct {
+				Spec json.RawMessage `json:"spec"`
+			} `json:"properties"`
+		}
+		if err := json.Unmarshal(v.Schema.OpenAPIV3Schema, &schema); err != nil {
+			return nil, GVK{}, errors.Wrap(err, "parsing openAPIV3Schema")
+		}
+		if len(schema.Properties.Spec) == 0 {
+			return nil, GVK{}, errors.Errorf("CRD version %s has no spec.properties.spec", v.Name)
+		}
+
+		return schema.Properties.Spec, GVK{
+			Group:   doc.Spec.Group,
+			Version: v.Name,
+			Kind:    doc.Spec.Names.Kind,
+		}, nil
+	}
+
+	return nil, GVK{}, errors.Errorf("CRD has no version %s (available: %v)", version, available)
+}
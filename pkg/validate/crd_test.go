@@ -0,0 +1,83 @@
+package validate
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const testCRD = `{
+  "spec": {
+    "group": "example.org",
+    "names": {"kind": "Thing"},
+    "versions": [
+      {
+        "name": "v1beta1",
+        "served": true,
+        "schema": {"openAPIV3Schema": {"properties": {"spec": {"properties": {"size": {"type": "string"}}}}}}
+      },
+      {
+        "name": "v1",
+        "served": true,
+        "schema": {"openAPIV3Schema": {"properties": {"spec": {"properties": {"forProvider": {"properties": {"region": {"type": "string"}}}}}}}}
+      },
+      {
+        "name": "v1alpha1",
+        "served": false,
+        "schema": {"openAPIV3Schema": {"properties": {"spec": {"properties": {"legacy": {"type": "string"}}}}}}
+      }
+    ]
+  }
+}`
+
+func TestSpecSchemaSelectsRequestedVersion(t *testing.T) {
+	schema, gvk, err := SpecSchema(testCRD, "v1")
+	if err != nil {
+		t.Fatalf("SpecSchema: %v", err)
+	}
+	if gvk != (GVK{Group: "example.org", Version: "v1", Kind: "Thing"}) {
+		t.Errorf("gvk = %+v", gvk)
+	}
+
+	var parsed struct {
+		Properties struct {
+			ForProvider json.RawMessage `json:"forProvider"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(schema, &parsed); err != nil {
+		t.Fatalf("unmarshaling returned schema: %v", err)
+	}
+	if len(parsed.Properties.ForProvider) == 0 {
+		t.Error("v1 schema should have properties.forProvider")
+	}
+}
+
+func TestSpecSchemaDifferentVersionsHaveDifferentSchemas(t *testing.T) {
+	v1beta1Schema, _, err := SpecSchema(testCRD, "v1beta1")
+	if err != nil {
+		t.Fatalf("SpecSchema(v1beta1): %v", err)
+	}
+
+	var parsed struct {
+		Properties struct {
+			Size json.RawMessage `json:"size"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(v1beta1Schema, &parsed); err != nil {
+		t.Fatalf("unmarshaling returned schema: %v", err)
+	}
+	if len(parsed.Properties.Size) == 0 {
+		t.Error("v1beta1 schema should have properties.size")
+	}
+}
+
+func TestSpecSchemaRejectsUnservedVersion(t *testing.T) {
+	if _, _, err := SpecSchema(testCRD, "v1alpha1"); err == nil {
+		t.Fatal("expected an error for a version that isn't served")
+	}
+}
+
+func TestSpecSchemaRejectsUnknownVersion(t *testing.T) {
+	if _, _, err := SpecSchema(testCRD, "v2"); err == nil {
+		t.Fatal("expected an error for a version that doesn't exist")
+	}
+}
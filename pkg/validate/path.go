@@ -0,0 +1,55 @@
+package validate
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FieldPathExists reports whether path (a Crossplane field path such as
+// "forProvider.region" or "forProvider.tags[0].key", relative to the
+// `spec` schema returned by SpecSchema) resolves to a property defined in
+// schema. Schemas that opt out of structural validation via
+// x-kubernetes-preserve-unknown-fields are treated as matching anything
+// below that point.
+func FieldPathExists(schema json.RawMessage, path string) (bool, error) {
+	var cur map[string]interface{}
+	if err := json.Unmarshal(schema, &cur); err != nil {
+		return false, errors.Wrap(err, "parsing schema")
+	}
+
+	for _, seg := range strings.Split(path, ".") {
+		if seg == "" {
+			continue
+		}
+		name := seg
+		if i := strings.IndexByte(seg, '['); i >= 0 {
+			name = seg[:i]
+		}
+
+		if preserved, _ := cur["x-kubernetes-preserve-unknown-fields"].(bool); preserved {
+			return true, nil
+		}
+
+		props, ok := cur["properties"].(map[string]interface{})
+		if !ok {
+			return false, nil
+		}
+		next, ok := props[name].(map[string]interface{})
+		if !ok {
+			return false, nil
+		}
+		cur = next
+
+		if strings.Contains(seg, "[") {
+			items, ok := cur["items"].(map[string]interface{})
+			if !ok {
+				return false, nil
+			}
+			cur = items
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,48 @@
+package validate
+
+import "testing"
+
+const testSpecSchema = `{
+  "properties": {
+    "forProvider": {
+      "type": "object",
+      "properties": {
+        "region": {"type": "string"},
+        "tags": {
+          "type": "array",
+          "items": {
+            "type": "object",
+            "properties": {"key": {"type": "string"}}
+          }
+        }
+      }
+    },
+    "unstructured": {
+      "x-kubernetes-preserve-unknown-fields": true
+    }
+  }
+}`
+
+func TestFieldPathExists(t *testing.T) {
+	cases := []struct {
+		path string
+		want bool
+	}{
+		{"forProvider.region", true},
+		{"forProvider.bogus", false},
+		{"forProvider.tags[0].key", true},
+		{"forProvider.tags[0].bogus", false},
+		{"unstructured.anything.goes", true},
+		{"bogusTopLevel", false},
+	}
+
+	for _, c := range cases {
+		got, err := FieldPathExists([]byte(testSpecSchema), c.path)
+		if err != nil {
+			t.Fatalf("FieldPathExists(%q): %v", c.path, err)
+		}
+		if got != c.want {
+			t.Errorf("FieldPathExists(%q) = %v, want %v", c.path, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Validator validates arbitrary values against a single compiled JSON Schema.
+type Validator struct {
+	schema *jsonschema.Schema
+}
+
+// Compile compiles schemaJSON (a JSON Schema document, e.g. a CRD's
+// spec.properties.spec) for repeated use by Validate.
+func Compile(schemaJSON json.RawMessage) (*Validator, error) {
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource("schema.json", bytes.NewReader(schemaJSON)); err != nil {
+		return nil, errors.Wrap(err, "loading schema")
+	}
+	schema, err := compiler.Compile("schema.json")
+	if err != nil {
+		return nil, errors.Wrap(err, "compiling schema")
+	}
+	return &Validator{schema: schema}, nil
+}
+
+// Finding is a single schema violation, identified by the JSON pointer
+// (relative to the value passed to Validate) where it occurred.
+type Finding struct {
+	Pointer string
+	Message string
+}
+
+// Validate checks instance (typically a map[string]interface{} decoded
+// from JSON/YAML) against the compiled schema and returns every violation.
+func (v *Validator) Validate(instance interface{}) []Finding {
+	err := v.schema.Validate(instance)
+	if err == nil {
+		return nil
+	}
+
+	ve, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return []Finding{{Message: err.Error()}}
+	}
+
+	var findings []Finding
+	var walk func(*jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			findings = append(findings, Finding{
+				Pointer: e.InstanceLocation,
+				Message: e.Message,
+			})
+			return
+		}
+		for _, c := range e.Causes {
+			walk(c)
+		}
+	}
+	walk(ve)
+
+	return findings
+}